@@ -0,0 +1,282 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package common provides functions used by the different crypt algorithm
+// implementations in this module, such as salt generation and the
+// non-standard base64 encoding used by the crypt(3) hash formats.
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"hash"
+	"strconv"
+	"time"
+)
+
+// Errors returned by Salt parsing and by the algorithm packages that embed
+// a Salt.
+var (
+	ErrSaltPrefix = errors.New("crypt/common: invalid magic prefix")
+	ErrSaltFormat = errors.New("crypt/common: invalid salt format")
+	ErrSaltRounds = errors.New("crypt/common: invalid rounds")
+)
+
+// base64Alphabet is the non-standard, reverse-order base64 alphabet shared
+// by the MD5, SHA-256, SHA-512 and yescrypt-family crypt(3) formats, used
+// by both Base64_24Bit and randBytes.
+const base64Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Salt holds the parameters common to the crypt(3)-style algorithms: the
+// magic prefix that identifies the algorithm in a hash, the allowed salt
+// length, and (where applicable) the allowed and default rounds.
+type Salt struct {
+	MagicPrefix   []byte
+	SaltLenMin    int
+	SaltLenMax    int
+	RoundsDefault int
+	RoundsMin     int
+	RoundsMax     int
+}
+
+// Generate returns a random salt of length saltLen, bounded by
+// [SaltLenMin, SaltLenMax] and prefixed with MagicPrefix.
+func (s *Salt) Generate(saltLen int) []byte {
+	if saltLen > s.SaltLenMax {
+		saltLen = s.SaltLenMax
+	} else if saltLen < s.SaltLenMin {
+		saltLen = s.SaltLenMin
+	}
+
+	salt := make([]byte, 0, len(s.MagicPrefix)+saltLen)
+	salt = append(salt, s.MagicPrefix...)
+	salt = append(salt, randBytes(saltLen)...)
+
+	return salt
+}
+
+// GenerateWRounds returns a random salt of length saltLen that also encodes
+// the given number of rounds, e.g. "$6$rounds=5000$<salt>".
+func (s *Salt) GenerateWRounds(saltLen, rounds int) []byte {
+	if rounds < s.RoundsMin {
+		rounds = s.RoundsMin
+	} else if rounds > s.RoundsMax {
+		rounds = s.RoundsMax
+	}
+
+	salt := s.Generate(saltLen)
+
+	out := make([]byte, 0, len(s.MagicPrefix)+16+len(salt))
+	out = append(out, s.MagicPrefix...)
+	out = append(out, []byte("rounds="+strconv.Itoa(rounds)+"$")...)
+	out = append(out, salt[len(s.MagicPrefix):]...)
+
+	return out
+}
+
+// GenerateWTarget returns a random salt of length saltLen encoding a
+// rounds value calibrated so that a single hash computed by calibrate
+// takes about target time on this machine. calibrate is ordinarily an
+// algorithm package's CalibrateRounds function, letting callers say "hash
+// this password with ~250ms of work on this box" instead of picking a
+// rounds number themselves.
+func (s *Salt) GenerateWTarget(saltLen int, target time.Duration, calibrate func(time.Duration) int) []byte {
+	return s.GenerateWRounds(saltLen, calibrate(target))
+}
+
+// CalibrateSampleRounds is the number of rounds CalibrateRounds hashes
+// with to measure the per-round cost on this machine.
+const CalibrateSampleRounds = 10000
+
+// CalibrateRounds benchmarks generate (ordinarily an algorithm package's
+// own Generate function) by timing one hash computed with
+// CalibrateSampleRounds rounds, and returns the highest rounds value
+// whose single-hash cost stays under target, clamped to
+// [s.RoundsMin, s.RoundsMax]. Algorithm packages expose this as their own
+// CalibrateRounds(target time.Duration) int so callers don't need to
+// plumb a Salt and Generate function through themselves.
+func (s *Salt) CalibrateRounds(generate func(key, salt []byte) (string, error), target time.Duration) int {
+	salt := s.GenerateWRounds(s.SaltLenMax, CalibrateSampleRounds)
+
+	start := time.Now()
+	if _, err := generate([]byte("crypt-calibration-sample"), salt); err != nil {
+		return s.RoundsDefault
+	}
+	perRound := time.Since(start) / CalibrateSampleRounds
+	if perRound <= 0 {
+		return s.RoundsMax
+	}
+
+	rounds := int(target / perRound)
+	if rounds < s.RoundsMin {
+		rounds = s.RoundsMin
+	} else if rounds > s.RoundsMax {
+		rounds = s.RoundsMax
+	}
+	return rounds
+}
+
+// randBytes returns n bytes picked from the 64-character alphabet used by
+// crypt(3) salts.
+func randBytes(n int) []byte {
+	buf := make([]byte, n)
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		panic("crypt/common: failed to read random bytes: " + err.Error())
+	}
+	for i, b := range raw {
+		buf[i] = base64Alphabet[int(b)%len(base64Alphabet)]
+	}
+
+	return buf
+}
+
+// Zeroize overwrites b with zero bytes. Call it on any key- or salt-derived
+// buffer once it is no longer needed, so sensitive material doesn't linger
+// in memory longer than necessary.
+func Zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Compute implements the SHA-crypt core shared by sha256_crypt and
+// sha512_crypt (Ulrich Drepper's algorithm): both reduce to this function
+// plus a digest constructor and an output permutation table, so a caller
+// can plug in any hash.Hash implementation (e.g. an accelerated AVX2 or
+// SHA-NI backend) without forking the package.
+//
+// permutation lists, for each output byte, the index into the final
+// rounds digest it is drawn from, in the order Base64_24Bit's
+// least-significant-byte-first encoding expects (the same reordering the
+// digest undergoes in every crypt(3) SHA-crypt variant). Unlike a plain
+// base64 encoding, a final group of fewer than 3 bytes is encoded as-is,
+// with no padding byte appended: Base64_24Bit already emits the right,
+// shorter number of characters for it. The returned slice is already
+// base64-encoded and ready to be appended after the salt.
+func Compute(newHash func() hash.Hash, permutation []int, key, salt []byte, rounds int) []byte {
+	key = append([]byte(nil), key...)
+	defer Zeroize(key)
+
+	// Compute alternate sum with input KEY, SALT, and KEY.
+	Alternate := newHash()
+	Alternate.Write(key)
+	Alternate.Write(salt)
+	Alternate.Write(key)
+	AlternateSum := Alternate.Sum(nil)
+	size := len(AlternateSum)
+
+	A := newHash()
+	A.Write(key)
+	A.Write(salt)
+	// Add for any character in the key one byte of the alternate sum.
+	i := len(key)
+	for ; i > size; i -= size {
+		A.Write(AlternateSum)
+	}
+	A.Write(AlternateSum[0:i])
+
+	// Take the binary representation of the length of the key and for every
+	// add the alternate sum, for every 0 the key.
+	for i = len(key); i > 0; i >>= 1 {
+		if (i & 1) != 0 {
+			A.Write(AlternateSum)
+		} else {
+			A.Write(key)
+		}
+	}
+	Asum := A.Sum(nil)
+	A.Reset()
+	Alternate.Reset()
+	Zeroize(AlternateSum)
+
+	// Start computation of P byte sequence.
+	P := newHash()
+	for i = 0; i < len(key); i++ {
+		P.Write(key)
+	}
+	Psum := P.Sum(nil)
+	Pseq := make([]byte, 0, len(key))
+	for i = len(key); i > size; i -= size {
+		Pseq = append(Pseq, Psum...)
+	}
+	Pseq = append(Pseq, Psum[0:i]...)
+	P.Reset()
+
+	// Start computation of S byte sequence.
+	S := newHash()
+	for i = 0; i < (16 + int(Asum[0])); i++ {
+		S.Write(salt)
+	}
+	Ssum := S.Sum(nil)
+	Sseq := make([]byte, 0, len(salt))
+	for i = len(salt); i > size; i -= size {
+		Sseq = append(Sseq, Ssum...)
+	}
+	Sseq = append(Sseq, Ssum[0:i]...)
+
+	// Csum must be its own copy, not an alias of Asum: the rounds loop
+	// below keeps reading and reassigning it long after Asum is wiped.
+	Csum := append([]byte(nil), Asum...)
+	Zeroize(Asum)
+
+	// Repeatedly run the collected hash value through the digest to burn
+	// CPU cycles.
+	for i = 0; i < rounds; i++ {
+		C := newHash()
+
+		if (i & 1) != 0 {
+			C.Write(Pseq)
+		} else {
+			C.Write(Csum)
+		}
+		if (i % 3) != 0 {
+			C.Write(Sseq)
+		}
+		if (i % 7) != 0 {
+			C.Write(Pseq)
+		}
+		if (i & 1) != 0 {
+			C.Write(Csum)
+		} else {
+			C.Write(Pseq)
+		}
+
+		Csum = C.Sum(nil)
+	}
+
+	permuted := make([]byte, len(permutation))
+	for i, p := range permutation {
+		permuted[i] = Csum[p]
+	}
+
+	Zeroize(Pseq)
+	Zeroize(Csum)
+
+	return Base64_24Bit(permuted)
+}
+
+// Base64_24Bit encodes src (of any length; each 3-byte group becomes 4
+// characters and a final group of 1 or 2 bytes becomes 2 or 3 characters,
+// with no padding) using the non-standard, reverse-order base64
+// alphabet shared by the MD5, SHA-256 and SHA-512 crypt formats.
+func Base64_24Bit(src []byte) []byte {
+	var out bytes.Buffer
+	var n, b uint
+
+	for _, c := range src {
+		b |= uint(c) << n
+		n += 8
+		for n >= 6 {
+			out.WriteByte(base64Alphabet[b&0x3f])
+			b >>= 6
+			n -= 6
+		}
+	}
+	if n != 0 {
+		out.WriteByte(base64Alphabet[b&0x3f])
+	}
+
+	return out.Bytes()
+}
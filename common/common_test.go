@@ -0,0 +1,55 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func testSalt() *Salt {
+	return &Salt{
+		MagicPrefix:   []byte("$t$"),
+		SaltLenMin:    1,
+		SaltLenMax:    16,
+		RoundsDefault: 1000,
+		RoundsMin:     1000,
+		RoundsMax:     999999999,
+	}
+}
+
+func TestCalibrateRoundsClampsToBounds(t *testing.T) {
+	s := testSalt()
+
+	slowGenerate := func(key, salt []byte) (string, error) {
+		time.Sleep(100 * time.Microsecond)
+		return "", nil
+	}
+
+	if got := s.CalibrateRounds(slowGenerate, 1); got != s.RoundsMin {
+		t.Errorf("CalibrateRounds(1ns) = %d, want RoundsMin %d", got, s.RoundsMin)
+	}
+	if got := s.CalibrateRounds(slowGenerate, 100*time.Hour); got != s.RoundsMax {
+		t.Errorf("CalibrateRounds(100h) = %d, want RoundsMax %d", got, s.RoundsMax)
+	}
+
+	errGenerate := func(key, salt []byte) (string, error) { return "", ErrSaltFormat }
+	if got := s.CalibrateRounds(errGenerate, time.Second); got != s.RoundsDefault {
+		t.Errorf("CalibrateRounds with failing generate = %d, want RoundsDefault %d", got, s.RoundsDefault)
+	}
+}
+
+func TestGenerateWTarget(t *testing.T) {
+	s := testSalt()
+
+	salt := s.GenerateWTarget(8, time.Hour, func(time.Duration) int { return 12345 })
+
+	if !bytes.HasPrefix(salt, s.MagicPrefix) {
+		t.Fatalf("GenerateWTarget salt %q missing magic prefix %q", salt, s.MagicPrefix)
+	}
+	if !bytes.Contains(salt, []byte("rounds=12345$")) {
+		t.Fatalf("GenerateWTarget salt %q missing encoded rounds", salt)
+	}
+}
@@ -0,0 +1,196 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// ScryptCore implements the scrypt-family BlockMix/ROMix construction
+// shared by the yescrypt and gost_yescrypt reference packages: PBKDF2-
+// HMAC-SHA256 seed expansion, one ROMix lane per p, each lane built from
+// repeated BlockMix-over-Salsa20/8 passes with an added pwxform S-box
+// mixing step. finish receives key and the resulting expanded buffer and
+// produces the final output digest, letting each caller plug in its own
+// finishing hash (yescrypt: HMAC-SHA256; gost_yescrypt: its gostHash
+// placeholder, a double SHA-256) in place of scrypt's own PBKDF2 finishing
+// step.
+func ScryptCore(key, salt []byte, n uint64, r, p uint, finish func(key, b []byte) []byte) []byte {
+	b := pbkdf2Sha256(key, salt, 1, 128*int(r)*int(p))
+
+	for i := 0; i < int(p); i++ {
+		block := b[i*128*int(r) : (i+1)*128*int(r)]
+		words := blockFromBytes(block)
+		smixBlock(words, n, r)
+		bytesFromBlock(words, block)
+	}
+
+	return finish(key, b)
+}
+
+// smixBlock runs a single p-lane through ROMix: n iterations of BlockMix,
+// each followed by the pwxform S-box mixing pass, XORed back pseudo-
+// randomly scrypt-style.
+func smixBlock(block []uint32, n uint64, r uint) {
+	words := 32 * int(r)
+	v := make([][]uint32, n)
+
+	x := make([]uint32, words)
+	copy(x, block)
+
+	for i := uint64(0); i < n; i++ {
+		v[i] = append([]uint32(nil), x...)
+		blockMix(x, r)
+		pwxform(x)
+	}
+	for i := uint64(0); i < n; i++ {
+		j := uint64(x[words-16]) % n
+		for k := range x {
+			x[k] ^= v[j][k]
+		}
+		blockMix(x, r)
+		pwxform(x)
+	}
+
+	copy(block, x)
+}
+
+// blockMix is scrypt's BlockMix built on the Salsa20/8 core.
+func blockMix(b []uint32, r uint) {
+	words := 16
+	x := make([]uint32, words)
+	copy(x, b[len(b)-words:])
+
+	y := make([]uint32, len(b))
+	for i := 0; i < int(2*r); i++ {
+		blk := b[i*words : (i+1)*words]
+		for k := range x {
+			x[k] ^= blk[k]
+		}
+		salsa208(x)
+		copy(y[i*words:(i+1)*words], x)
+	}
+
+	// Scrypt's even/odd de-interleave.
+	out := make([]uint32, len(b))
+	half := int(r)
+	for i := 0; i < half; i++ {
+		copy(out[i*words:(i+1)*words], y[(2*i)*words:(2*i+1)*words])
+		copy(out[(half+i)*words:(half+i+1)*words], y[(2*i+1)*words:(2*i+2)*words])
+	}
+	copy(b, out)
+}
+
+// pwxform is a simplified stand-in for yescrypt's parallel S-box mixing
+// transform: it is not a faithful bit-for-bit reproduction of the upstream
+// pwxform S-boxes, but it serves the same purpose of adding cache-hard,
+// data-dependent mixing on top of the Salsa20/8 BlockMix pass.
+func pwxform(x []uint32) {
+	for i := 0; i < len(x); i += 2 {
+		a, b := x[i], x[i+1]
+		a += b
+		b ^= bits.RotateLeft32(a, 7)
+		a ^= bits.RotateLeft32(b, 13)
+		x[i], x[i+1] = a, b
+	}
+}
+
+// salsa208 applies the 8-round Salsa20 core in place.
+func salsa208(b []uint32) {
+	x := append([]uint32(nil), b...)
+
+	for i := 0; i < 4; i++ {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := range b {
+		b[i] += x[i]
+	}
+}
+
+func blockFromBytes(b []byte) []uint32 {
+	w := make([]uint32, len(b)/4)
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return w
+}
+
+func bytesFromBlock(w []uint32, out []byte) {
+	for i, v := range w {
+		binary.LittleEndian.PutUint32(out[i*4:], v)
+	}
+}
+
+// pbkdf2Sha256 is a minimal single-purpose PBKDF2-HMAC-SHA256, used here
+// only to expand/condense fixed-size blocks rather than as a general KDF.
+func pbkdf2Sha256(password, salt []byte, iter, dkLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (dkLen + hashLen - 1) / hashLen
+
+	dk := make([]byte, 0, numBlocks*hashLen)
+	buf := make([]byte, 4)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(nil)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		dk = append(dk, t...)
+	}
+
+	return dk[:dkLen]
+}
@@ -0,0 +1,85 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package common
+
+import "bytes"
+
+// ScryptParams holds the scrypt-family cost parameters (N_log2, r, p) plus
+// a flags byte, as encoded in a yescrypt-family ($y$/$gy$) hash. It is
+// shared by the yescrypt and gost_yescrypt reference packages, which only
+// differ in their finishing hash.
+type ScryptParams struct {
+	Flags byte
+	NLog2 uint
+	R     uint
+	P     uint
+}
+
+// EncodeScryptParams Base64_24Bit-encodes p as the 4-byte (flags, N_log2,
+// r, p) tuple used by yescrypt-family hash formats.
+func EncodeScryptParams(p ScryptParams) []byte {
+	buf := []byte{p.Flags, byte(p.NLog2), byte(p.R), byte(p.P)}
+	return Base64_24Bit(buf)
+}
+
+// DecodeScryptParams reverses EncodeScryptParams' Base64_24Bit encoding of
+// the 4-byte (flags, N_log2, r, p) tuple. ok is false if enc doesn't decode
+// to a full 4-byte tuple using the base64Alphabet encoding.
+func DecodeScryptParams(enc []byte) (p ScryptParams, ok bool) {
+	var n, b uint
+	raw := make([]byte, 0, 4)
+
+	for _, c := range enc {
+		idx := bytes.IndexByte([]byte(base64Alphabet), c)
+		if idx < 0 {
+			return ScryptParams{}, false
+		}
+		b |= uint(idx) << n
+		n += 6
+		for n >= 8 {
+			raw = append(raw, byte(b))
+			b >>= 8
+			n -= 8
+		}
+	}
+	if len(raw) < 4 {
+		return ScryptParams{}, false
+	}
+
+	return ScryptParams{
+		Flags: raw[0],
+		NLog2: uint(raw[1]),
+		R:     uint(raw[2]),
+		P:     uint(raw[3]),
+	}, true
+}
+
+// SplitScryptSalt splits a yescrypt-family salt/hash string of the form
+// "<prefix><params>$<salt>$<hash>" (the trailing "$<hash>" is optional)
+// into its raw params and salt tokens, given that raw has already been
+// confirmed to start with the algorithm's magic prefix. ok is false if raw
+// doesn't have enough "$"-separated fields to hold both.
+func SplitScryptSalt(raw []byte) (params, salt []byte, ok bool) {
+	toks := bytes.Split(raw, []byte{'$'})
+	if len(toks) < 4 {
+		return nil, nil, false
+	}
+	return toks[2], toks[3], true
+}
+
+// NewScryptSalt returns a fresh salt/hash prefix of the form
+// "<prefix><params>$<salt>", embedding p as the encoded parameters, for use
+// as the len(salt)==0 default path of a yescrypt-family Generate.
+func (s *Salt) NewScryptSalt(saltLen int, p ScryptParams) []byte {
+	salt := s.Generate(saltLen)
+	encParams := EncodeScryptParams(p)
+
+	out := make([]byte, 0, len(s.MagicPrefix)+len(encParams)+1+len(salt))
+	out = append(out, s.MagicPrefix...)
+	out = append(out, encParams...)
+	out = append(out, '$')
+	out = append(out, salt[len(s.MagicPrefix):]...)
+
+	return out
+}
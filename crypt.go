@@ -0,0 +1,90 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package crypt provides a common interface to the various crypt(3)-style
+// password hashing algorithms implemented by this module's subpackages
+// (currently sha512_crypt and sha256_crypt).
+//
+// Algorithm packages register themselves with RegisterCrypter, keyed by the
+// magic prefix they use in a hash (e.g. "$6$" for sha512_crypt). Importing
+// an algorithm package for its side effect is enough to make it available
+// through New and NewFromHash:
+//
+//	import _ "github.com/kless/crypt/sha512_crypt"
+//
+//	c, err := crypt.New("$6$")
+//	hash, err := c.Generate(key, nil)
+//	...
+//	c2, err := crypt.NewFromHash(hash)
+//	err = c2.Verify(key, hash)
+package crypt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyMismatch is returned by Verify when the hash is well-formed but was
+// not produced from the given key.
+var ErrKeyMismatch = errors.New("crypt: hashedKey does not match the given key")
+
+// Crypter is implemented by every crypt(3)-style algorithm package in this
+// module.
+type Crypter interface {
+	// Generate performs the hashing algorithm, returning a full hash
+	// suitable for storage and later verification. If salt is empty, a
+	// random one is generated.
+	Generate(key, salt []byte) (string, error)
+
+	// Verify hashes key using the parameters found in hash and compares
+	// the result against hash in constant time. It returns ErrKeyMismatch
+	// if they differ, or a parse error if hash is malformed.
+	Verify(key []byte, hash string) error
+
+	// Cost returns the work factor (e.g. rounds) encoded in hash.
+	Cost(hash string) (int, error)
+
+	// SetOptions configures non-default parameters, such as the number of
+	// rounds, for subsequent calls to Generate. Supported options vary by
+	// algorithm.
+	SetOptions(opts map[string]interface{}) error
+}
+
+// newCrypter is the constructor an algorithm package registers for its
+// magic prefix.
+type newCrypter func() Crypter
+
+var registry = make(map[string]newCrypter)
+
+// RegisterCrypter makes a Crypter constructor available under the given
+// magic prefix (e.g. "$6$"). It is meant to be called from an algorithm
+// package's init function. RegisterCrypter panics if called twice for the
+// same prefix.
+func RegisterCrypter(prefix string, ctor func() Crypter) {
+	if _, dup := registry[prefix]; dup {
+		panic("crypt: RegisterCrypter called twice for prefix " + prefix)
+	}
+	registry[prefix] = ctor
+}
+
+// New returns a new Crypter for the algorithm identified by the given magic
+// prefix (e.g. "$6$"). The algorithm's package must have been imported
+// beforehand so that it had a chance to register itself.
+func New(prefix string) (Crypter, error) {
+	ctor, ok := registry[prefix]
+	if !ok {
+		return nil, fmt.Errorf("crypt: no algorithm registered for prefix %q", prefix)
+	}
+	return ctor(), nil
+}
+
+// NewFromHash returns a new Crypter for the algorithm that produced hash,
+// determined by matching hash's magic prefix against the registry.
+func NewFromHash(hash string) (Crypter, error) {
+	for prefix, ctor := range registry {
+		if len(hash) >= len(prefix) && hash[:len(prefix)] == prefix {
+			return ctor(), nil
+		}
+	}
+	return nil, fmt.Errorf("crypt: no algorithm registered for hash %q", hash)
+}
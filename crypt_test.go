@@ -0,0 +1,58 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import "testing"
+
+// fakeCrypter is a minimal Crypter used to exercise the registry without
+// depending on any real algorithm package.
+type fakeCrypter struct{}
+
+func (fakeCrypter) Generate(key, salt []byte) (string, error)    { return "$fake$", nil }
+func (fakeCrypter) Verify(key []byte, hash string) error         { return nil }
+func (fakeCrypter) Cost(hash string) (int, error)                { return 0, nil }
+func (fakeCrypter) SetOptions(opts map[string]interface{}) error { return nil }
+
+func TestNewUnknownPrefix(t *testing.T) {
+	if _, err := New("$nope$"); err == nil {
+		t.Fatal("New(unregistered prefix) = nil error, want non-nil")
+	}
+}
+
+func TestNewFromHashUnknownPrefix(t *testing.T) {
+	if _, err := NewFromHash("$nope$somehash"); err == nil {
+		t.Fatal("NewFromHash(unregistered prefix) = nil error, want non-nil")
+	}
+}
+
+func TestRegisterCrypterAndDispatch(t *testing.T) {
+	RegisterCrypter("$fake$", func() Crypter { return fakeCrypter{} })
+
+	c, err := New("$fake$")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, ok := c.(fakeCrypter); !ok {
+		t.Fatalf("New returned %T, want fakeCrypter", c)
+	}
+
+	c2, err := NewFromHash("$fake$somehash")
+	if err != nil {
+		t.Fatalf("NewFromHash: %v", err)
+	}
+	if _, ok := c2.(fakeCrypter); !ok {
+		t.Fatalf("NewFromHash returned %T, want fakeCrypter", c2)
+	}
+}
+
+func TestRegisterCrypterPanicsOnDup(t *testing.T) {
+	RegisterCrypter("$fakedup$", func() Crypter { return fakeCrypter{} })
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("RegisterCrypter(dup prefix) did not panic")
+		}
+	}()
+	RegisterCrypter("$fakedup$", func() Crypter { return fakeCrypter{} })
+}
@@ -0,0 +1,127 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gost_yescrypt is a pure-Go scaffold for gost-yescrypt, the
+// GOST R 34.11-2012 ("Streebog") variant of yescrypt used by some
+// Russian-market libxcrypt builds, identified by the magic prefix "$gy$".
+//
+// IMPORTANT: this package does NOT produce or verify real gost-yescrypt
+// hashes. It shares yescrypt's BlockMix/ROMix/pwxform core (see the
+// sibling yescrypt and common packages) and, like that package, uses a
+// simplified pwxform stand-in rather than the real S-boxes. It also
+// finishes with gostHash, a placeholder that computes SHA-256 twice and
+// does not implement Streebog-256 at all. Both gaps mean its
+// digests will never match a real "$gy$" hash. For that reason it
+// deliberately does not call crypt.RegisterCrypter: wiring a verifier
+// that always rejects real gost-yescrypt hashes into crypt.New/
+// NewFromHash would be worse than leaving "$gy$" unhandled. Treat
+// Generate/Verify here as a structural reference for a real
+// implementation, not a working algorithm.
+package gost_yescrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/kless/crypt/common"
+)
+
+// Salt describes the magic prefix and salt length bounds used by
+// gost-yescrypt.
+var Salt = &common.Salt{
+	MagicPrefix: []byte("$gy$"),
+	SaltLenMin:  1,
+	SaltLenMax:  32,
+}
+
+const (
+	defaultNLog2 = 12 // N = 4096
+	defaultR     = 32
+	defaultP     = 1
+)
+
+var (
+	ErrSaltFormat = errors.New("gost_yescrypt: invalid salt format")
+	ErrParams     = errors.New("gost_yescrypt: invalid or unsupported parameters")
+)
+
+// Generate performs the gost-yescrypt hashing algorithm, returning a full
+// hash suitable for storage and later verification. If salt is empty, a
+// random salt and the default flavor-0 parameters are used.
+//
+// See the package doc comment: the resulting hash does not interoperate
+// with real gost-yescrypt implementations.
+func Generate(key, salt []byte) (string, error) {
+	if len(salt) == 0 {
+		salt = Salt.NewScryptSalt(Salt.SaltLenMax, common.ScryptParams{
+			NLog2: defaultNLog2,
+			R:     defaultR,
+			P:     defaultP,
+		})
+	}
+	if !bytes.HasPrefix(salt, Salt.MagicPrefix) {
+		return "", common.ErrSaltPrefix
+	}
+
+	// "$gy$<params>$<salt>$<hash>" splits into five tokens: an empty
+	// leading token, "gy", params, salt and (if present) hash.
+	rawParams, rawSalt, ok := common.SplitScryptSalt(salt)
+	if !ok {
+		return "", ErrSaltFormat
+	}
+	p, ok := common.DecodeScryptParams(rawParams)
+	if !ok {
+		return "", ErrParams
+	}
+	salt = rawSalt
+
+	if len(salt) > Salt.SaltLenMax {
+		salt = salt[:Salt.SaltLenMax]
+	}
+
+	dk := smix(key, salt, p)
+
+	out := make([]byte, 0, len(Salt.MagicPrefix)+64)
+	out = append(out, Salt.MagicPrefix...)
+	out = append(out, common.EncodeScryptParams(p)...)
+	out = append(out, '$')
+	out = append(out, salt...)
+	out = append(out, '$')
+	out = append(out, common.Base64_24Bit(dk)...)
+
+	return string(out), nil
+}
+
+// smix runs the same ROMix/BlockMix/pwxform core as yescrypt
+// (common.ScryptCore), finishing with gostHash instead of an
+// HMAC-SHA256.
+func smix(key, salt []byte, p common.ScryptParams) []byte {
+	n := uint64(1) << p.NLog2
+
+	return common.ScryptCore(key, salt, n, p.R, p.P, func(_, b []byte) []byte {
+		return gostHash(b)
+	})
+}
+
+// gostHash is a placeholder finishing function standing in for GOST
+// R 34.11-2012 (Streebog-256); see the package doc comment.
+func gostHash(b []byte) []byte {
+	h := sha256.Sum256(b)
+	h2 := sha256.Sum256(h[:])
+	return h2[:]
+}
+
+// Verify hashes key using the parameters and salt encoded in hash, and
+// compares the result in constant time.
+//
+// See the package doc comment: this never matches a real gost-yescrypt
+// hash.
+func Verify(key []byte, hash string) bool {
+	newHash, err := Generate(key, []byte(hash))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) == 1
+}
@@ -0,0 +1,186 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package migrate helps operators move bulk password dumps produced by
+// atheme/anope/libxcrypt-based IRC and SASL services off legacy crypt(3)
+// hashes and onto the algorithms implemented by this module's
+// subpackages.
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedLine is returned by Scanner.Err when a line that looked
+// like a user record could not be parsed.
+var ErrMalformedLine = errors.New("migrate: malformed MU line")
+
+// ErrMalformedHash is returned by shaCryptRounds when a hash doesn't have
+// enough "$"-separated fields to hold a salt. NeedsRehash returns no error:
+// it reports any hash whose rounds can't be parsed as needing a rehash.
+var ErrMalformedHash = errors.New("migrate: malformed hash")
+
+// Entry is a single imported (account, hash) pair read from a services
+// database dump.
+type Entry struct {
+	Account string
+	Hash    string
+}
+
+// Scanner reads atheme/anope-style services database dumps line by line
+// and extracts the "MU <uid> <name> <hash> <email> <ts> ..." user records
+// they contain, skipping every other line. Its usage mirrors bufio.Scanner:
+//
+//	sc := migrate.NewScanner(r)
+//	for sc.Scan() {
+//		e := sc.Entry()
+//		...
+//	}
+//	if err := sc.Err(); err != nil {
+//		...
+//	}
+type Scanner struct {
+	sc    *bufio.Scanner
+	entry Entry
+	err   error
+}
+
+// NewScanner returns a Scanner that reads from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{sc: bufio.NewScanner(r)}
+}
+
+// Scan advances the Scanner to the next "MU" record, skipping any other
+// line, and reports whether one was found.
+func (s *Scanner) Scan() bool {
+	for s.sc.Scan() {
+		line := s.sc.Text()
+		if !strings.HasPrefix(line, "MU ") {
+			continue
+		}
+		e, err := parseMULine(line)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		s.entry = e
+		return true
+	}
+	s.err = s.sc.Err()
+	return false
+}
+
+// Entry returns the most recently scanned record.
+func (s *Scanner) Entry() Entry {
+	return s.entry
+}
+
+// Err returns the first non-EOF error encountered by Scan.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// parseMULine parses a line of the form
+// "MU <uid> <name> <hash> <email> <registered-ts> ...".
+func parseMULine(line string) (Entry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "MU" {
+		return Entry{}, ErrMalformedLine
+	}
+	return Entry{Account: fields[2], Hash: fields[3]}, nil
+}
+
+// Policy configures which imported hashes NeedsRehash flags as due for
+// replacement.
+type Policy struct {
+	// RejectMD5Crypt flags every "$1$" hash, regardless of rounds.
+	RejectMD5Crypt bool
+	// MinRounds flags any "$5$"/"$6$" hash whose encoded rounds fall
+	// below this value. Zero means no minimum.
+	MinRounds int
+}
+
+// NeedsRehash reports whether hash should be replaced with a native hash
+// from this module's algorithm packages, based on policy.
+func NeedsRehash(hash string, policy Policy) bool {
+	switch {
+	case strings.HasPrefix(hash, "$1$"):
+		return policy.RejectMD5Crypt
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		if policy.MinRounds == 0 {
+			return false
+		}
+		rounds, err := shaCryptRounds(hash)
+		if err != nil {
+			return true
+		}
+		return rounds < policy.MinRounds
+	default:
+		// Unknown or already-modern (e.g. "$y$") scheme: leave it alone.
+		return false
+	}
+}
+
+// shaCryptRoundsDefault and shaCryptRoundsPrefix mirror the constants
+// sha256_crypt.Salt.RoundsDefault/sha512_crypt.Salt.RoundsDefault and the
+// "rounds=" token both packages encode in a hash.
+const (
+	shaCryptRoundsDefault = 5000
+	shaCryptRoundsPrefix  = "rounds="
+)
+
+// shaCryptRounds parses the rounds value encoded in a "$5$"/"$6$" hash,
+// mirroring sha256_crypt.Crypter.Cost/sha512_crypt.Crypter.Cost's own
+// parsing. It's duplicated here rather than called through
+// crypt.NewFromHash so that NeedsRehash works whether or not the caller
+// has imported sha256_crypt/sha512_crypt for their registration side
+// effect.
+func shaCryptRounds(hash string) (int, error) {
+	toks := strings.Split(hash, "$")
+	if len(toks) < 3 {
+		return 0, ErrMalformedHash
+	}
+	if !strings.HasPrefix(toks[2], shaCryptRoundsPrefix) {
+		return shaCryptRoundsDefault, nil
+	}
+	return strconv.Atoi(toks[2][len(shaCryptRoundsPrefix):])
+}
+
+// wrapPrefix tags a Wrap envelope. It deliberately isn't a crypt(3) magic
+// prefix any algorithm package registers, so crypt.NewFromHash fails
+// closed on a wrapped value instead of misdispatching it to newAlgo's
+// Crypter.
+const wrapPrefix = "$wrapped$"
+
+// Wrap embeds oldHash, produced by an algorithm this module does not
+// implement, inside an envelope tagged with wrapPrefix and newAlgo's
+// magic prefix (e.g. "$6$") so a mixed-algorithm storage column can
+// still record which algorithm oldHash came from. The wrapped value is a
+// reversible envelope, not a hash of a hash: a caller with only the
+// stored hash can never rehash a legacy password without the plaintext,
+// so verification must Unwrap and keep using the legacy algorithm's own
+// Verify against the recovered hash, until the user's next successful
+// login lets the caller replace the wrapper with a real newAlgo hash of
+// the now-known plaintext. A wrapped value is not itself dispatchable by
+// crypt.NewFromHash; callers must call Unwrap first.
+func Wrap(oldHash, newAlgo string) string {
+	return wrapPrefix + strings.TrimPrefix(newAlgo, "$") + oldHash
+}
+
+// Unwrap recovers the legacy hash embedded by Wrap, and reports whether
+// wrapped was in fact a Wrap envelope.
+func Unwrap(wrapped string) (oldHash string, ok bool) {
+	rest := strings.TrimPrefix(wrapped, wrapPrefix)
+	if rest == wrapped {
+		return "", false
+	}
+	idx := strings.Index(rest, "$")
+	if idx < 0 {
+		return "", false
+	}
+	return rest[idx+1:], true
+}
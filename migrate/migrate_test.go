@@ -0,0 +1,78 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"strings"
+	"testing"
+)
+
+const dump = `SID *
+MU 1 alice $6$rounds=5000$saltstring$hash alice@example.com 1600000000
+garbage line with no prefix
+MU 2 bob $1$saltstring$hash bob@example.com 1600000001
+`
+
+func TestScanner(t *testing.T) {
+	sc := NewScanner(strings.NewReader(dump))
+
+	var got []Entry
+	for sc.Scan() {
+		got = append(got, sc.Entry())
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	want := []Entry{
+		{Account: "alice", Hash: "$6$rounds=5000$saltstring$hash"},
+		{Account: "bob", Hash: "$1$saltstring$hash"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, e := range got {
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	tests := []struct {
+		hash   string
+		policy Policy
+		want   bool
+	}{
+		{"$1$saltstring$hash", Policy{RejectMD5Crypt: true}, true},
+		{"$1$saltstring$hash", Policy{RejectMD5Crypt: false}, false},
+		{"$6$rounds=1000$saltstring$hash", Policy{MinRounds: 5000}, true},
+		{"$6$rounds=10000$saltstring$hash", Policy{MinRounds: 5000}, false},
+		{"$5$saltstring$hash", Policy{MinRounds: 5000}, false}, // default 5000 rounds, not below min
+		{"$y$params$saltstring$hash", Policy{RejectMD5Crypt: true, MinRounds: 1 << 30}, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsRehash(tt.hash, tt.policy); got != tt.want {
+			t.Errorf("NeedsRehash(%q, %+v) = %v, want %v", tt.hash, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestWrapUnwrapRoundTrip(t *testing.T) {
+	const oldHash = "$1$saltstring$legacyhash"
+
+	wrapped := Wrap(oldHash, "$6$")
+
+	got, ok := Unwrap(wrapped)
+	if !ok {
+		t.Fatalf("Unwrap(%q) ok = false, want true", wrapped)
+	}
+	if got != oldHash {
+		t.Fatalf("Unwrap(%q) = %q, want %q", wrapped, got, oldHash)
+	}
+
+	if _, ok := Unwrap(oldHash); ok {
+		t.Fatalf("Unwrap(%q) ok = true, want false", oldHash)
+	}
+}
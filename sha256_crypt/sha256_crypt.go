@@ -0,0 +1,192 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sha256_crypt implements Ulrich Drepper's SHA256-crypt password
+// hashing algorithm.
+//
+// The specification for this algorithm can be found here:
+// http://www.akkadia.org/drepper/SHA-crypt.txt
+package sha256_crypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kless/crypt"
+	"github.com/kless/crypt/common"
+)
+
+var Salt = &common.Salt{
+	MagicPrefix:   []byte("$5$"),
+	SaltLenMin:    1,
+	SaltLenMax:    16,
+	RoundsMin:     1000,
+	RoundsMax:     999999999,
+	RoundsDefault: 5000,
+}
+
+var _rounds = []byte("rounds=")
+
+// permutation lists, for each output byte of the base64-encoded hash, the
+// index into the 32-byte rounds digest it is drawn from. See
+// common.Compute.
+var permutation = []int{
+	20, 10, 0,
+	11, 1, 21,
+	2, 22, 12,
+	23, 13, 3,
+	14, 4, 24,
+	5, 25, 15,
+	26, 16, 6,
+	17, 7, 27,
+	8, 28, 18,
+	29, 19, 9,
+	30, 31,
+}
+
+// Generate performs the SHA256-crypt hashing algorithm, returning a full hash
+// suitable for storage and later password verification.
+//
+// If the salt is empty, a randomly-generated salt will be generated with a
+// length of SaltLenMax and RoundsDefault number of rounds.
+func Generate(key, salt []byte) (string, error) {
+	var rounds int
+	var isRoundsDef bool
+
+	if len(salt) == 0 {
+		salt = Salt.GenerateWRounds(Salt.SaltLenMax, Salt.RoundsDefault)
+	}
+	if !bytes.HasPrefix(salt, Salt.MagicPrefix) {
+		return "", common.ErrSaltPrefix
+	}
+
+	saltToks := bytes.Split(salt, []byte{'$'})
+
+	if len(saltToks) < 3 {
+		return "", common.ErrSaltFormat
+	}
+
+	if bytes.HasPrefix(saltToks[2], _rounds) {
+		isRoundsDef = true
+		pr, err := strconv.ParseInt(string(saltToks[2][7:]), 10, 32)
+		if err != nil {
+			return "", common.ErrSaltRounds
+		}
+		rounds = int(pr)
+		if rounds < Salt.RoundsMin {
+			rounds = Salt.RoundsMin
+		} else if rounds > Salt.RoundsMax {
+			rounds = Salt.RoundsMax
+		}
+		salt = saltToks[3]
+	} else {
+		rounds = Salt.RoundsDefault
+		salt = saltToks[2]
+	}
+
+	if len(salt) > 16 {
+		salt = salt[0:16]
+	}
+
+	out := make([]byte, 0, 91)
+	out = append(out, Salt.MagicPrefix...)
+	if isRoundsDef {
+		out = append(out, []byte("rounds="+strconv.Itoa(rounds)+"$")...)
+	}
+	out = append(out, salt...)
+	out = append(out, '$')
+	out = append(out, common.Compute(sha256.New, permutation, key, salt, rounds)...)
+
+	return string(out), nil
+}
+
+// CalibrateRounds benchmarks the local CPU and returns the highest rounds
+// value whose single-hash cost stays under target, clamped to
+// [Salt.RoundsMin, Salt.RoundsMax].
+func CalibrateRounds(target time.Duration) int {
+	return Salt.CalibrateRounds(Generate, target)
+}
+
+// Verify hashes a key using the same salt parameter as the given in the hash,
+// and if the results match in constant time, it returns true.
+func Verify(key []byte, hash string) bool {
+	newHash, err := Generate(key, []byte(hash))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) == 1
+}
+
+func init() {
+	crypt.RegisterCrypter(string(Salt.MagicPrefix), New)
+}
+
+// Crypter implements crypt.Crypter for the SHA256-crypt algorithm.
+type Crypter struct {
+	rounds      int
+	isRoundsSet bool
+}
+
+// New returns a new crypt.Crypter computing SHA256-crypt hashes.
+func New() crypt.Crypter {
+	return &Crypter{}
+}
+
+// Generate performs the SHA256-crypt hashing algorithm, returning a full
+// hash suitable for storage and later password verification.
+func (c *Crypter) Generate(key, salt []byte) (string, error) {
+	if len(salt) == 0 && c.isRoundsSet {
+		salt = Salt.GenerateWRounds(Salt.SaltLenMax, c.rounds)
+	}
+	return Generate(key, salt)
+}
+
+// Verify hashes key using the salt and rounds encoded in hash, and compares
+// the result against hash in constant time.
+func (c *Crypter) Verify(key []byte, hash string) error {
+	newHash, err := Generate(key, []byte(hash))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) != 1 {
+		return crypt.ErrKeyMismatch
+	}
+	return nil
+}
+
+// Cost returns the number of rounds encoded in hash.
+func (c *Crypter) Cost(hash string) (int, error) {
+	saltToks := bytes.Split([]byte(hash), []byte{'$'})
+	if len(saltToks) < 3 {
+		return 0, common.ErrSaltFormat
+	}
+	if !bytes.HasPrefix(saltToks[2], _rounds) {
+		return Salt.RoundsDefault, nil
+	}
+	rounds, err := strconv.Atoi(string(saltToks[2][len(_rounds):]))
+	if err != nil {
+		return 0, common.ErrSaltRounds
+	}
+	return rounds, nil
+}
+
+// SetOptions configures the number of rounds used by subsequent calls to
+// Generate when no explicit salt is given. The only supported option is
+// "rounds" (int).
+func (c *Crypter) SetOptions(opts map[string]interface{}) error {
+	rounds, ok := opts["rounds"]
+	if !ok {
+		return nil
+	}
+	n, ok := rounds.(int)
+	if !ok {
+		return fmt.Errorf("sha256_crypt: rounds option must be an int")
+	}
+	c.rounds = n
+	c.isRoundsSet = true
+	return nil
+}
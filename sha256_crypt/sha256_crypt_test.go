@@ -0,0 +1,61 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha256_crypt
+
+import (
+	"testing"
+
+	"github.com/kless/crypt"
+)
+
+// Known-answer vector from http://www.akkadia.org/drepper/SHA-crypt.txt.
+const (
+	katKey  = "Hello world!"
+	katSalt = "$5$saltstring"
+	katHash = "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"
+)
+
+func TestGenerateKAT(t *testing.T) {
+	hash, err := Generate([]byte(katKey), []byte(katSalt))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if hash != katHash {
+		t.Fatalf("Generate(%q, %q) = %q, want %q", katKey, katSalt, hash, katHash)
+	}
+}
+
+func TestVerifyKAT(t *testing.T) {
+	if !Verify([]byte(katKey), katHash) {
+		t.Fatalf("Verify(%q, %q) = false, want true", katKey, katHash)
+	}
+	if Verify([]byte("wrong password"), katHash) {
+		t.Fatalf("Verify(wrong password, %q) = true, want false", katHash)
+	}
+}
+
+func TestCryptDispatch(t *testing.T) {
+	c, err := crypt.New("$5$")
+	if err != nil {
+		t.Fatalf("crypt.New: %v", err)
+	}
+	hash, err := c.Generate([]byte(katKey), []byte(katSalt))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if hash != katHash {
+		t.Fatalf("Generate(%q, %q) = %q, want %q", katKey, katSalt, hash, katHash)
+	}
+
+	c2, err := crypt.NewFromHash(hash)
+	if err != nil {
+		t.Fatalf("crypt.NewFromHash: %v", err)
+	}
+	if err := c2.Verify([]byte(katKey), hash); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := c2.Verify([]byte("wrong password"), hash); err != crypt.ErrKeyMismatch {
+		t.Fatalf("Verify(wrong password) = %v, want crypt.ErrKeyMismatch", err)
+	}
+}
@@ -12,8 +12,12 @@ package sha512_crypt
 import (
 	"bytes"
 	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/kless/crypt"
 	"github.com/kless/crypt/common"
 )
 
@@ -28,6 +32,34 @@ var Salt = &common.Salt{
 
 var _rounds = []byte("rounds=")
 
+// permutation lists, for each output byte of the base64-encoded hash, the
+// index into the 64-byte rounds digest it is drawn from. See
+// common.Compute.
+var permutation = []int{
+	42, 21, 0,
+	1, 43, 22,
+	23, 2, 44,
+	45, 24, 3,
+	4, 46, 25,
+	26, 5, 47,
+	48, 27, 6,
+	7, 49, 28,
+	29, 8, 50,
+	51, 30, 9,
+	10, 52, 31,
+	32, 11, 53,
+	54, 33, 12,
+	13, 55, 34,
+	35, 14, 56,
+	57, 36, 15,
+	16, 58, 37,
+	38, 17, 59,
+	60, 39, 18,
+	19, 61, 40,
+	41, 20, 62,
+	63,
+}
+
 // Generate performs the SHA512-crypt hashing algorithm, returning a full hash
 // suitable for storage and later password verification.
 //
@@ -72,114 +104,6 @@ func Generate(key, salt []byte) (string, error) {
 		salt = salt[0:16]
 	}
 
-	// Compute alternate SHA512 sum with input KEY, SALT, and KEY.
-	Alternate := sha512.New()
-	Alternate.Write(key)
-	Alternate.Write(salt)
-	Alternate.Write(key)
-	AlternateSum := Alternate.Sum(nil) // 64 bytes
-
-	A := sha512.New()
-	A.Write(key)
-	A.Write(salt)
-	// Add for any character in the key one byte of the alternate sum.
-	i := len(key)
-	for ; i > 64; i -= 64 {
-		A.Write(AlternateSum)
-	}
-	A.Write(AlternateSum[0:i])
-
-	// Take the binary representation of the length of the key and for every add
-	// the alternate sum, for every 0 the key.
-	for i = len(key); i > 0; i >>= 1 {
-		if (i & 1) != 0 {
-			A.Write(AlternateSum)
-		} else {
-			A.Write(key)
-		}
-	}
-	Asum := A.Sum(nil)
-
-	// Clean sensitive data for security.
-	go func() {
-		A.Reset()
-		Alternate.Reset()
-		for i = 0; i < len(AlternateSum); i++ {
-			AlternateSum[i] = 0
-		}
-	}()
-
-	// Start computation of P byte sequence.
-	P := sha512.New()
-	// For every character in the password add the entire password.
-	for i = 0; i < len(key); i++ {
-		P.Write(key)
-	}
-	Psum := P.Sum(nil)
-	// Create byte sequence P.
-	Pseq := make([]byte, 0, len(key))
-	for i = len(key); i > 64; i -= 64 {
-		Pseq = append(Pseq, Psum...)
-	}
-	Pseq = append(Pseq, Psum[0:i]...)
-
-	// Start computation of S byte sequence.
-	S := sha512.New()
-	for i = 0; i < (16 + int(Asum[0])); i++ {
-		S.Write(salt)
-	}
-	Ssum := S.Sum(nil)
-	// Create byte sequence S.
-	Sseq := make([]byte, 0, len(salt))
-	for i = len(salt); i > 64; i -= 64 {
-		Sseq = append(Sseq, Ssum...)
-	}
-	Sseq = append(Sseq, Ssum[0:i]...)
-
-	Csum := Asum
-
-	// Clean sensitive data for security.
-	go func() {
-		A.Reset()
-		Alternate.Reset()
-		P.Reset()
-
-		for i = 0; i < len(Asum); i++ {
-			Asum[i] = 0
-		}
-		for i = 0; i < len(AlternateSum); i++ {
-			AlternateSum[i] = 0
-		}
-	}()
-
-	// Repeatedly run the collected hash value through SHA512 to burn CPU cycles.
-	for i = 0; i < rounds; i++ {
-		C := sha512.New()
-
-		// Add key or last result.
-		if (i & 1) != 0 {
-			C.Write(Pseq)
-		} else {
-			C.Write(Csum)
-		}
-		// Add salt for numbers not divisible by 3.
-		if (i % 3) != 0 {
-			C.Write(Sseq)
-		}
-		// Add key for numbers not divisible by 7.
-		if (i % 7) != 0 {
-			C.Write(Pseq)
-		}
-		// Add key or last result.
-		if (i & 1) != 0 {
-			C.Write(Csum)
-		} else {
-			C.Write(Pseq)
-		}
-
-		Csum = C.Sum(nil)
-	}
-
 	out := make([]byte, 0, 123)
 	out = append(out, Salt.MagicPrefix...)
 	if isRoundsDef {
@@ -187,48 +111,94 @@ func Generate(key, salt []byte) (string, error) {
 	}
 	out = append(out, salt...)
 	out = append(out, '$')
-	out = append(out, common.Base64_24Bit([]byte{
-		Csum[42], Csum[21], Csum[0],
-		Csum[1], Csum[43], Csum[22],
-		Csum[23], Csum[2], Csum[44],
-		Csum[45], Csum[24], Csum[3],
-		Csum[4], Csum[46], Csum[25],
-		Csum[26], Csum[5], Csum[47],
-		Csum[48], Csum[27], Csum[6],
-		Csum[7], Csum[49], Csum[28],
-		Csum[29], Csum[8], Csum[50],
-		Csum[51], Csum[30], Csum[9],
-		Csum[10], Csum[52], Csum[31],
-		Csum[32], Csum[11], Csum[53],
-		Csum[54], Csum[33], Csum[12],
-		Csum[13], Csum[55], Csum[34],
-		Csum[35], Csum[14], Csum[56],
-		Csum[57], Csum[36], Csum[15],
-		Csum[16], Csum[58], Csum[37],
-		Csum[38], Csum[17], Csum[59],
-		Csum[60], Csum[39], Csum[18],
-		Csum[19], Csum[61], Csum[40],
-		Csum[41], Csum[20], Csum[62],
-		Csum[63],
-	})...)
-
-	// Clean sensitive data.
-	for i = 0; i < len(Pseq); i++ {
-		Pseq[i] = 0
-	}
-	for i = 0; i < len(Csum); i++ {
-		Csum[i] = 0
-	}
+	out = append(out, common.Compute(sha512.New, permutation, key, salt, rounds)...)
 
 	return string(out), nil
 }
 
+// CalibrateRounds benchmarks the local CPU and returns the highest rounds
+// value whose single-hash cost stays under target, clamped to
+// [Salt.RoundsMin, Salt.RoundsMax].
+func CalibrateRounds(target time.Duration) int {
+	return Salt.CalibrateRounds(Generate, target)
+}
+
 // Verify hashes a key using the same salt parameter as the given in the hash,
-// and if the results match, it returns true.
+// and if the results match in constant time, it returns true.
 func Verify(key []byte, hash string) bool {
 	newHash, err := Generate(key, []byte(hash))
 	if err != nil {
 		return false
 	}
-	return newHash == hash
+	return subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) == 1
+}
+
+func init() {
+	crypt.RegisterCrypter(string(Salt.MagicPrefix), New)
+}
+
+// Crypter implements crypt.Crypter for the SHA512-crypt algorithm.
+type Crypter struct {
+	rounds      int
+	isRoundsSet bool
+}
+
+// New returns a new crypt.Crypter computing SHA512-crypt hashes.
+func New() crypt.Crypter {
+	return &Crypter{}
+}
+
+// Generate performs the SHA512-crypt hashing algorithm, returning a full
+// hash suitable for storage and later password verification.
+func (c *Crypter) Generate(key, salt []byte) (string, error) {
+	if len(salt) == 0 && c.isRoundsSet {
+		salt = Salt.GenerateWRounds(Salt.SaltLenMax, c.rounds)
+	}
+	return Generate(key, salt)
+}
+
+// Verify hashes key using the salt and rounds encoded in hash, and compares
+// the result against hash in constant time.
+func (c *Crypter) Verify(key []byte, hash string) error {
+	newHash, err := Generate(key, []byte(hash))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) != 1 {
+		return crypt.ErrKeyMismatch
+	}
+	return nil
+}
+
+// Cost returns the number of rounds encoded in hash.
+func (c *Crypter) Cost(hash string) (int, error) {
+	saltToks := bytes.Split([]byte(hash), []byte{'$'})
+	if len(saltToks) < 3 {
+		return 0, common.ErrSaltFormat
+	}
+	if !bytes.HasPrefix(saltToks[2], _rounds) {
+		return Salt.RoundsDefault, nil
+	}
+	rounds, err := strconv.Atoi(string(saltToks[2][len(_rounds):]))
+	if err != nil {
+		return 0, common.ErrSaltRounds
+	}
+	return rounds, nil
+}
+
+// SetOptions configures the number of rounds used by subsequent calls to
+// Generate when no explicit salt is given. The only supported option is
+// "rounds" (int).
+func (c *Crypter) SetOptions(opts map[string]interface{}) error {
+	rounds, ok := opts["rounds"]
+	if !ok {
+		return nil
+	}
+	n, ok := rounds.(int)
+	if !ok {
+		return fmt.Errorf("sha512_crypt: rounds option must be an int")
+	}
+	c.rounds = n
+	c.isRoundsSet = true
+	return nil
 }
@@ -0,0 +1,140 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package yescrypt is a pure-Go scaffold for the yescrypt password hashing
+// scheme used as the default crypt(3) algorithm on modern Debian, Fedora
+// and SUSE systems.
+//
+// IMPORTANT: this package does NOT produce or verify real yescrypt hashes.
+// Its pwxform S-box mixing step (see the unexported pwxform function in
+// the sibling common package) is explicitly a simplified stand-in, not a
+// faithful reproduction of upstream yescrypt's S-boxes, so the digests it
+// computes will never match a real "$y$" hash produced by libxcrypt or
+// found in /etc/shadow. For that reason it deliberately does not call
+// crypt.RegisterCrypter: wiring a verifier that always rejects real
+// yescrypt hashes into crypt.New/NewFromHash would be worse than leaving
+// "$y$" unhandled. Treat Generate/Verify here as a structural reference
+// for a real implementation, not a working algorithm.
+//
+// Only "flavor 0" (native yescrypt, no ROM sharing) is scaffolded: the
+// salt format is
+//
+//	$y$<params>$<salt>$<hash>
+//
+// where <params> is a compact base64 encoding of the scrypt-family cost
+// parameters (N_log2, r, p) plus a flags byte.
+//
+// The specification this package follows is:
+// https://github.com/openwall/yescrypt/blob/main/YESCRYPT.txt
+package yescrypt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/kless/crypt/common"
+)
+
+// Salt describes the magic prefix and salt length bounds used by yescrypt.
+var Salt = &common.Salt{
+	MagicPrefix: []byte("$y$"),
+	SaltLenMin:  1,
+	SaltLenMax:  32,
+}
+
+// Default flavor-0 cost parameters, equivalent to yescrypt's "j9T" default
+// setting used by libxcrypt.
+const (
+	defaultNLog2 = 12 // N = 4096
+	defaultR     = 32
+	defaultP     = 1
+)
+
+var (
+	ErrSaltFormat = errors.New("yescrypt: invalid salt format")
+	ErrParams     = errors.New("yescrypt: invalid or unsupported parameters")
+)
+
+// Generate performs the yescrypt hashing algorithm, returning a full hash
+// suitable for storage and later verification. If salt is empty, a random
+// salt and the default flavor-0 parameters are used.
+//
+// See the package doc comment: the resulting hash does not interoperate
+// with real yescrypt implementations.
+func Generate(key, salt []byte) (string, error) {
+	if len(salt) == 0 {
+		salt = Salt.NewScryptSalt(Salt.SaltLenMax, common.ScryptParams{
+			NLog2: defaultNLog2,
+			R:     defaultR,
+			P:     defaultP,
+		})
+	}
+	if !bytes.HasPrefix(salt, Salt.MagicPrefix) {
+		return "", common.ErrSaltPrefix
+	}
+
+	// "$y$<params>$<salt>$<hash>" splits into five tokens: an empty
+	// leading token, "y", params, salt and (if present) hash.
+	rawParams, rawSalt, ok := common.SplitScryptSalt(salt)
+	if !ok {
+		return "", ErrSaltFormat
+	}
+	p, ok := common.DecodeScryptParams(rawParams)
+	if !ok {
+		return "", ErrParams
+	}
+	salt = rawSalt
+
+	if len(salt) > Salt.SaltLenMax {
+		salt = salt[:Salt.SaltLenMax]
+	}
+
+	dk := smix(key, salt, p)
+
+	out := make([]byte, 0, len(Salt.MagicPrefix)+64)
+	out = append(out, Salt.MagicPrefix...)
+	out = append(out, common.EncodeScryptParams(p)...)
+	out = append(out, '$')
+	out = append(out, salt...)
+	out = append(out, '$')
+	out = append(out, common.Base64_24Bit(dk)...)
+
+	return string(out), nil
+}
+
+// smix runs yescrypt's ROMix/BlockMix/pwxform core (common.ScryptCore),
+// finishing with an HMAC-SHA256 over the expanded buffer in place of
+// scrypt's own PBKDF2 finishing step.
+func smix(key, salt []byte, p common.ScryptParams) []byte {
+	n := uint64(1) << p.NLog2
+
+	return common.ScryptCore(key, salt, n, p.R, p.P, func(key, b []byte) []byte {
+		dk := deriveMACKey(key, b)
+		mac := hmac.New(sha256.New, dk)
+		mac.Write(b)
+		return mac.Sum(nil)
+	})
+}
+
+// deriveMACKey derives the 32-byte key used as the HMAC key in smix's
+// finishing step.
+func deriveMACKey(key, salt []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+// Verify hashes key using the parameters and salt encoded in hash, and
+// compares the result in constant time.
+//
+// See the package doc comment: this never matches a real yescrypt hash.
+func Verify(key []byte, hash string) bool {
+	newHash, err := Generate(key, []byte(hash))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(newHash), []byte(hash)) == 1
+}
@@ -0,0 +1,37 @@
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yescrypt
+
+import (
+	"testing"
+
+	"github.com/kless/crypt/common"
+)
+
+func TestGenerateVerifyRoundTrip(t *testing.T) {
+	const key = "Hello world!"
+
+	hash, err := Generate([]byte(key), nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !Verify([]byte(key), hash) {
+		t.Fatalf("Verify(%q, %q) = false, want true", key, hash)
+	}
+	if Verify([]byte("wrong password"), hash) {
+		t.Fatalf("Verify(wrong password, %q) = true, want false", hash)
+	}
+}
+
+func TestGenerateRejectsMalformedSalt(t *testing.T) {
+	if _, err := Generate([]byte("key"), []byte("$nope$")); err != common.ErrSaltPrefix {
+		t.Fatalf("Generate(wrong prefix) = %v, want common.ErrSaltPrefix", err)
+	}
+	if _, err := Generate([]byte("key"), []byte("$y$onlytwofields")); err != ErrSaltFormat {
+		t.Fatalf("Generate(too few fields) = %v, want ErrSaltFormat", err)
+	}
+	if _, err := Generate([]byte("key"), []byte("$y$!!!!$saltstring")); err != ErrParams {
+		t.Fatalf("Generate(bad params) = %v, want ErrParams", err)
+	}
+}